@@ -0,0 +1,181 @@
+// Copyright 2013 The go-logger Authors. All rights reserved.
+// This code is MIT licensed. See the LICENSE file for more info.
+
+package logger
+
+import (
+	"fmt"
+	"os"
+)
+
+// These functions operate on the package's default Logger object, which
+// writes to os.Stderr at the WARNING level. They exist so that callers who
+// do not need a custom Logger can log immediately without constructing one.
+//
+// Each helper reimplements its Logger counterpart's level gate and calls
+// Fprint directly, rather than delegating to the method, at the same
+// calldepth (2) as the *Logger method it mirrors: both are one frame above
+// Fprint, so calldepth still resolves to the caller of the package function
+// rather than to the helper itself.
+
+// Debug calls Debug on the default logger.
+func Debug(v ...interface{}) (n int, err error) {
+	if log.Level > DEBUG {
+		return 0, nil
+	}
+	return log.Fprint(2, DEBUG, fmt.Sprint(v...), nil)
+}
+
+// Debugln calls Debugln on the default logger.
+func Debugln(v ...interface{}) (n int, err error) {
+	if log.Level > DEBUG {
+		return 0, nil
+	}
+	return log.Fprint(2, DEBUG, fmt.Sprintln(v...), nil)
+}
+
+// Debugf calls Debugf on the default logger.
+func Debugf(format string, v ...interface{}) (n int, err error) {
+	if log.Level > DEBUG {
+		return 0, nil
+	}
+	return log.Fprint(2, DEBUG, fmt.Sprintf(format, v...), nil)
+}
+
+// Info calls Info on the default logger.
+func Info(v ...interface{}) (n int, err error) {
+	if log.Level > INFO {
+		return 0, nil
+	}
+	return log.Fprint(2, INFO, fmt.Sprint(v...), nil)
+}
+
+// Infoln calls Infoln on the default logger.
+func Infoln(v ...interface{}) (n int, err error) {
+	if log.Level > INFO {
+		return 0, nil
+	}
+	return log.Fprint(2, INFO, fmt.Sprintln(v...), nil)
+}
+
+// Infof calls Infof on the default logger.
+func Infof(format string, v ...interface{}) (n int, err error) {
+	if log.Level > INFO {
+		return 0, nil
+	}
+	return log.Fprint(2, INFO, fmt.Sprintf(format, v...), nil)
+}
+
+// Warning calls Warning on the default logger.
+func Warning(v ...interface{}) (n int, err error) {
+	if log.Level > WARNING {
+		return 0, nil
+	}
+	return log.Fprint(2, WARNING, fmt.Sprint(v...), nil)
+}
+
+// Warningln calls Warningln on the default logger.
+func Warningln(v ...interface{}) (n int, err error) {
+	if log.Level > WARNING {
+		return 0, nil
+	}
+	return log.Fprint(2, WARNING, fmt.Sprintln(v...), nil)
+}
+
+// Warningf calls Warningf on the default logger.
+func Warningf(format string, v ...interface{}) (n int, err error) {
+	if log.Level > WARNING {
+		return 0, nil
+	}
+	return log.Fprint(2, WARNING, fmt.Sprintf(format, v...), nil)
+}
+
+// Error calls Error on the default logger.
+func Error(v ...interface{}) (n int, err error) {
+	if log.Level > ERROR {
+		return 0, nil
+	}
+	return log.Fprint(2, ERROR, fmt.Sprint(v...), nil)
+}
+
+// Errorln calls Errorln on the default logger.
+func Errorln(v ...interface{}) (n int, err error) {
+	if log.Level > ERROR {
+		return 0, nil
+	}
+	return log.Fprint(2, ERROR, fmt.Sprintln(v...), nil)
+}
+
+// Errorf calls Errorf on the default logger.
+func Errorf(format string, v ...interface{}) (n int, err error) {
+	if log.Level > ERROR {
+		return 0, nil
+	}
+	return log.Fprint(2, ERROR, fmt.Sprintf(format, v...), nil)
+}
+
+// Critical calls Critical on the default logger.
+func Critical(v ...interface{}) (n int, err error) {
+	if log.Level > CRITICAL {
+		return 0, nil
+	}
+	return log.Fprint(2, CRITICAL, fmt.Sprint(v...), nil)
+}
+
+// Criticalln calls Criticalln on the default logger.
+func Criticalln(v ...interface{}) (n int, err error) {
+	if log.Level > CRITICAL {
+		return 0, nil
+	}
+	return log.Fprint(2, CRITICAL, fmt.Sprintln(v...), nil)
+}
+
+// Criticalf calls Criticalf on the default logger.
+func Criticalf(format string, v ...interface{}) (n int, err error) {
+	if log.Level > CRITICAL {
+		return 0, nil
+	}
+	return log.Fprint(2, CRITICAL, fmt.Sprintf(format, v...), nil)
+}
+
+// Fatal calls Fatal on the default logger.
+func Fatal(v ...interface{}) {
+	log.Fprint(2, CRITICAL, fmt.Sprint(v...), nil)
+	os.Exit(1)
+}
+
+// Fatalln calls Fatalln on the default logger.
+func Fatalln(v ...interface{}) {
+	log.Fprint(2, CRITICAL, fmt.Sprintln(v...), nil)
+	os.Exit(1)
+}
+
+// Fatalf calls Fatalf on the default logger.
+func Fatalf(format string, v ...interface{}) {
+	log.Fprint(2, CRITICAL, fmt.Sprintf(format, v...), nil)
+	os.Exit(1)
+}
+
+// Panic calls Panic on the default logger.
+func Panic(v ...interface{}) {
+	s := fmt.Sprint(v...)
+	log.Fprint(2, CRITICAL, s, nil)
+	panic(s)
+}
+
+// Panicln calls Panicln on the default logger.
+func Panicln(v ...interface{}) {
+	s := fmt.Sprintln(v...)
+	log.Fprint(2, CRITICAL, s, nil)
+	panic(s)
+}
+
+// Panicf calls Panicf on the default logger.
+func Panicf(format string, v ...interface{}) {
+	s := fmt.Sprintf(format, v...)
+	log.Fprint(2, CRITICAL, s, nil)
+	panic(s)
+}
+
+// V calls V on the default logger.
+func V(n int) Verbose { return log.vAt(n, 2) }
@@ -0,0 +1,204 @@
+// Copyright 2013 The go-logger Authors. All rights reserved.
+// This code is MIT licensed. See the LICENSE file for more info.
+
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Entry is the structured representation of a single log line, built by
+// Fprint after level gating and caller resolution, and handed to a
+// Logger's Encoder to be turned into bytes.
+type Entry struct {
+	Time    time.Time
+	Level   level
+	File    string
+	Line    int
+	Message string
+	Fields  map[string]interface{}
+}
+
+// Encoder turns an Entry into bytes written to w. Implementations must not
+// retain e.Fields beyond the call, since it is shared with the Logger that
+// produced it.
+type Encoder interface {
+	Encode(w io.Writer, e Entry) error
+}
+
+// TextEncoder renders an Entry the way Logger has always rendered output:
+// using l.DateFormat, l.Prefix, and, when l.Colors and Lansi are set,
+// AnsiEscape-colored level text. Any fields attached via WithField(s) are
+// appended as logfmt-style key=value pairs.
+type TextEncoder struct {
+	l *Logger
+}
+
+// Encode implements Encoder.
+func (t *TextEncoder) Encode(w io.Writer, e Entry) error {
+	l := t.l
+	var b strings.Builder
+	b.WriteString(l.Prefix)
+	b.WriteByte(' ')
+	b.WriteString(e.Time.Format(l.DateFormat))
+	if e.Level != noLevel {
+		levelText := e.Level.String()
+		if l.Colors && l.Flags&Lansi != 0 {
+			levelText = AnsiEscape(levelColor(e.Level), levelText, OFF)
+		}
+		b.WriteByte(' ')
+		b.WriteString(levelText)
+	}
+	if l.Flags&(Lshortfile|Llongfile) != 0 && e.File != "" {
+		file := e.File
+		if l.Flags&Lshortfile != 0 {
+			file = file[strings.LastIndexByte(file, '/')+1:]
+		}
+		fmt.Fprintf(&b, " %s:%d", file, e.Line)
+	}
+	b.WriteByte(' ')
+	b.WriteString(strings.TrimSuffix(e.Message, "\n"))
+	for _, k := range sortedKeys(e.Fields) {
+		b.WriteByte(' ')
+		writeLogfmtPair(&b, k, fmt.Sprint(e.Fields[k]))
+	}
+	if b.Len() == 0 || b.String()[b.Len()-1] != '\n' {
+		b.WriteByte('\n')
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// levelColor returns the ansi color used to highlight lvl when the logger
+// has colored output enabled.
+func levelColor(lvl level) string {
+	switch lvl {
+	case DEBUG:
+		return CYAN
+	case INFO:
+		return GREEN
+	case WARNING:
+		return YELLOW
+	case ERROR:
+		return RED
+	case CRITICAL:
+		return MAGENTA
+	}
+	return OFF
+}
+
+// JSONEncoder renders an Entry as a single JSON object per line, with keys
+// in the stable order ts, level, caller, msg, then fields sorted by name.
+type JSONEncoder struct{}
+
+// Encode implements Encoder.
+func (JSONEncoder) Encode(w io.Writer, e Entry) error {
+	var b strings.Builder
+	b.WriteByte('{')
+	writeJSONPair(&b, true, "ts", e.Time.Format(time.RFC3339Nano))
+	if e.Level != noLevel {
+		writeJSONPair(&b, false, "level", e.Level.String())
+	}
+	if e.File != "" {
+		writeJSONPair(&b, false, "caller", fmt.Sprintf("%s:%d", e.File, e.Line))
+	}
+	writeJSONPair(&b, false, "msg", e.Message)
+	for _, k := range sortedKeys(e.Fields) {
+		val, err := json.Marshal(e.Fields[k])
+		if err != nil {
+			val = []byte(`"?"`)
+		}
+		b.WriteByte(',')
+		key, _ := json.Marshal(k)
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(val)
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeJSONPair appends a `"key":"value"` pair to b, preceding it with a
+// comma unless first is true.
+func writeJSONPair(b *strings.Builder, first bool, key, value string) {
+	if !first {
+		b.WriteByte(',')
+	}
+	k, _ := json.Marshal(key)
+	v, _ := json.Marshal(value)
+	b.Write(k)
+	b.WriteByte(':')
+	b.Write(v)
+}
+
+// LogfmtEncoder renders an Entry as space-separated key=value pairs in the
+// order ts, level, caller, msg, then fields sorted by name, quoting values
+// that contain spaces, "=", or control characters.
+type LogfmtEncoder struct{}
+
+// Encode implements Encoder.
+func (LogfmtEncoder) Encode(w io.Writer, e Entry) error {
+	var b strings.Builder
+	writeLogfmtPair(&b, "ts", e.Time.Format(time.RFC3339Nano))
+	if e.Level != noLevel {
+		b.WriteByte(' ')
+		writeLogfmtPair(&b, "level", e.Level.String())
+	}
+	if e.File != "" {
+		b.WriteByte(' ')
+		writeLogfmtPair(&b, "caller", fmt.Sprintf("%s:%d", e.File, e.Line))
+	}
+	b.WriteByte(' ')
+	writeLogfmtPair(&b, "msg", strings.TrimSuffix(e.Message, "\n"))
+	for _, k := range sortedKeys(e.Fields) {
+		b.WriteByte(' ')
+		writeLogfmtPair(&b, k, fmt.Sprint(e.Fields[k]))
+	}
+	b.WriteByte('\n')
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeLogfmtPair appends a `key=value` pair to b, quoting value if it
+// contains a space, "=", '"', or a control character (e.g. "\n", "\t")
+// that would otherwise split the line.
+func writeLogfmtPair(b *strings.Builder, key, value string) {
+	b.WriteString(key)
+	b.WriteByte('=')
+	if needsLogfmtQuote(value) {
+		b.WriteString(strconv.Quote(value))
+	} else {
+		b.WriteString(value)
+	}
+}
+
+// needsLogfmtQuote reports whether value must be quoted to stay on a
+// single logfmt line.
+func needsLogfmtQuote(value string) bool {
+	if strings.ContainsAny(value, ` ="`) {
+		return true
+	}
+	for _, r := range value {
+		if r < ' ' || r == 0x7f {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedKeys returns the keys of fields in sorted order.
+func sortedKeys(fields map[string]interface{}) []string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -8,11 +8,13 @@
 package logger
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"os"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"text/template"
 	"time"
 )
@@ -80,28 +82,39 @@ var (
 // Write method. A Logger can be used simultaneously from multiple goroutines;
 // it guarantees to serialize access to the Writer.
 type Logger struct {
-	mu         sync.Mutex         // Ensures atomic writes
-	buf        []byte             // For marshaling output to write
-	Colors     bool               // Enable/Disable colored output
-	DateFormat string             // time.RubyDate is the default format
-	Flags      int                // Properties of the output
-	Level      level              // The default level is warning
-	Template   *template.Template // The format order of the output
-	Prefix     string             // Inserted into every logging output
-	Stream     io.Writer          // Destination for output
-}
+	mu         sync.Mutex             // Ensures atomic writes
+	buf        []byte                 // For marshaling output to write
+	Colors     bool                   // Enable/Disable colored output
+	DateFormat string                 // time.RubyDate is the default format
+	Flags      int                    // Properties of the output
+	Level      level                  // The default level is warning
+	Template   *template.Template     // The format order of the output
+	Prefix     string                 // Inserted into every logging output
+	Stream     io.Writer              // Convenience shim; New wraps it as the first Sink
+	Verbosity  int                    // V(n) is enabled for n <= Verbosity
+	vmodule    *vmoduleConfig         // Per-file/per-package verbosity overrides
+	vmodCache  atomic.Value           // holds *sync.Map of runtime.Caller pc -> matched vmodule level (noVmodMatch if none); swapped atomically so SetVModule can invalidate it without racing vAt's unlocked reads
+	Encoder    Encoder                // Turns an Entry into bytes; defaults to a *TextEncoder
+	fields     map[string]interface{} // Immutable key-values set by WithField(s)
+	sinksMu    sync.RWMutex           // Guards Sinks
+	Sinks      []*Sink                // Fan-out destinations; populated from Stream by New
 
-// formatOutput is used by Output() to apply the desired output format using
-// the logTemplate. Using this template, an output string is built containing
-// the desired structure such as prefix, date, and file + line number.
-func (l *Logger) formatOutput(buf *[]byte, t time.Time, file string,
-	line int, text string) {
-	l.buf = append(l.buf, t.Format(l.dateFormat)...)
-	if len(text) > 0 && text[len(text)-1] != '\n' {
-		l.buf = append(l.buf, '\n')
-	}
+	// SummarizeDropped, when true, appends to the next admitted entry at a
+	// call site a count of the entries SampleEvery/RateLimit suppressed
+	// there since the previous admitted entry.
+	SummarizeDropped bool
+	limiters         int32 // atomic: 1 once SampleEvery or RateLimit has been used
+	sampleEvery      uint64
+	rateMu           sync.RWMutex
+	rateLimits       map[level]rateSpec
+	shards           [siteShards]siteShard
 }
 
+// noLevel is used internally by the unleveled Print family to indicate that
+// a message carries no severity and so encoders should omit the level field
+// rather than index into levels with an invalid value.
+const noLevel level = -1
+
 // Output is used by all of the logging functions to send output to the output
 // stream.
 //
@@ -112,32 +125,105 @@ func (l *Logger) formatOutput(buf *[]byte, t time.Time, file string,
 //
 // stream will be used as the output stream the text will be written to. If
 // stream is nil, the stream value contained in the logger object is used.
-func (l *Logger) Fprint(calldepth int,
+func (l *Logger) Fprint(calldepth int, lvl level,
 	text string, stream io.Writer) (n int, err error) {
 	now := time.Now()
 	var file string
 	var line int
-	l.mu.Lock()
-	defer l.mu.Unlock()
+	var haveLoc bool
 	if l.Flags&(Lshortfile|Llongfile) != 0 {
-		// release lock while getting caller info - it's expensive.
-		l.mu.Unlock()
 		var ok bool
 		_, file, line, ok = runtime.Caller(calldepth)
 		if !ok {
 			file = "???"
 			line = 0
 		}
+		haveLoc = true
+	}
+	if l.limitersInstalled() {
+		if !haveLoc {
+			if _, f, ln, ok := runtime.Caller(calldepth); ok {
+				file, line = f, ln
+			}
+		}
+		res := l.admit(lvl, file, line, now)
+		if !res.ok {
+			return 0, nil
+		}
+		if res.repeated > 0 {
+			text = fmt.Sprintf("%s (previous message repeated %d times in the last %s)", text, res.repeated, res.since)
+		}
+	}
+	entry := Entry{Time: now, Level: lvl, File: file, Line: line, Message: text, Fields: l.fields}
+	if stream != nil {
 		l.mu.Lock()
+		defer l.mu.Unlock()
+		buf := bytes.NewBuffer(l.buf[:0])
+		if err = l.Encoder.Encode(buf, entry); err != nil {
+			return 0, err
+		}
+		l.buf = buf.Bytes()
+		return stream.Write(l.buf)
 	}
-	l.buf = l.buf[:0]
-	l.formatOutput(&l.buf, now, file, line, text)
-	if stream == nil {
-		n, err = l.Stream.Write(l.buf)
+	// With no explicit stream the entry goes to l.Sinks. New seeds the
+	// first Sink from Stream; a Stream assigned after New is not used here.
+	return l.dispatch(entry)
+}
+
+// WithFields returns a shallow copy of l that carries fields merged on top
+// of any fields l already carries. The returned Logger shares l's Stream,
+// Template, and other configuration, including sampling and rate-limit
+// settings; only the fields map, the encoder's binding to the new Logger,
+// and the per-call-site sampling and rate-limit state (which starts empty)
+// differ.
+func (l *Logger) WithFields(fields map[string]interface{}) *Logger {
+	merged := make(map[string]interface{}, len(l.fields)+len(fields))
+	for k, v := range l.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	l.sinksMu.RLock()
+	sinks := append([]*Sink(nil), l.Sinks...)
+	l.sinksMu.RUnlock()
+	clone := &Logger{
+		Colors:     l.Colors,
+		DateFormat: l.DateFormat,
+		Flags:      l.Flags,
+		Level:      l.Level,
+		Template:   l.Template,
+		Prefix:     l.Prefix,
+		Stream:     l.Stream,
+		Verbosity:  l.Verbosity,
+		vmodule:    l.vmodule,
+		fields:     merged,
+		Sinks:      sinks,
+	}
+	clone.vmodCache.Store(&sync.Map{})
+	clone.SummarizeDropped = l.SummarizeDropped
+	atomic.StoreUint64(&clone.sampleEvery, atomic.LoadUint64(&l.sampleEvery))
+	atomic.StoreInt32(&clone.limiters, atomic.LoadInt32(&l.limiters))
+	l.rateMu.RLock()
+	for lv, spec := range l.rateLimits {
+		if clone.rateLimits == nil {
+			clone.rateLimits = make(map[level]rateSpec, len(l.rateLimits))
+		}
+		clone.rateLimits[lv] = spec
+	}
+	l.rateMu.RUnlock()
+	if te, ok := l.Encoder.(*TextEncoder); ok && te.l == l {
+		clone.Encoder = &TextEncoder{l: clone}
 	} else {
-		n, err = stream.Write(l.buf)
+		clone.Encoder = l.Encoder
 	}
-	return int(n), err
+	return clone
+}
+
+// WithField is a convenience wrapper around WithFields for a single
+// key-value pair.
+func (l *Logger) WithField(k string, v interface{}) *Logger {
+	return l.WithFields(map[string]interface{}{k: v})
 }
 
 // Print sends output to the standard logger output stream regardless of
@@ -145,7 +231,7 @@ func (l *Logger) Fprint(calldepth int,
 // added between operands when neither is a string. It returns the number of
 // bytes written and any write error encountered.
 func (l *Logger) Print(v ...interface{}) (n int, err error) {
-	return l.Fprint(2, fmt.Sprint(v...), os.Stdout)
+	return l.Fprint(2, noLevel, fmt.Sprint(v...), os.Stdout)
 }
 
 // Println formats using the default formats for its operands and writes to
@@ -153,14 +239,201 @@ func (l *Logger) Print(v ...interface{}) (n int, err error) {
 // appended. It returns the number of bytes written and any write error
 // encountered.
 func (l *Logger) Println(v ...interface{}) (n int, err error) {
-	return l.Fprint(2, fmt.Sprintln(v...), os.Stdout)
+	return l.Fprint(2, noLevel, fmt.Sprintln(v...), os.Stdout)
 }
 
 // Printf formats according to a format specifier and writes to standard
 // output. It returns the number of bytes written and any write error
 // encountered.
 func (l *Logger) Printf(format string, v ...interface{}) (n int, err error) {
-	return l.Fprint(2, fmt.Sprintf(format, v...), os.Stdout)
+	return l.Fprint(2, noLevel, fmt.Sprintf(format, v...), os.Stdout)
+}
+
+// Debug sends output to the logger's stream if l.Level is DEBUG or lower.
+// Spaces are added between operands when neither is a string. It returns the
+// number of bytes written and any write error encountered.
+func (l *Logger) Debug(v ...interface{}) (n int, err error) {
+	if l.Level > DEBUG {
+		return 0, nil
+	}
+	return l.Fprint(2, DEBUG, fmt.Sprint(v...), nil)
+}
+
+// Debugln formats using the default formats for its operands and writes to
+// the logger's stream if l.Level is DEBUG or lower. Spaces are always added
+// between operands and a newline is appended.
+func (l *Logger) Debugln(v ...interface{}) (n int, err error) {
+	if l.Level > DEBUG {
+		return 0, nil
+	}
+	return l.Fprint(2, DEBUG, fmt.Sprintln(v...), nil)
+}
+
+// Debugf formats according to a format specifier and writes to the logger's
+// stream if l.Level is DEBUG or lower.
+func (l *Logger) Debugf(format string, v ...interface{}) (n int, err error) {
+	if l.Level > DEBUG {
+		return 0, nil
+	}
+	return l.Fprint(2, DEBUG, fmt.Sprintf(format, v...), nil)
+}
+
+// Info sends output to the logger's stream if l.Level is INFO or lower.
+// Spaces are added between operands when neither is a string.
+func (l *Logger) Info(v ...interface{}) (n int, err error) {
+	if l.Level > INFO {
+		return 0, nil
+	}
+	return l.Fprint(2, INFO, fmt.Sprint(v...), nil)
+}
+
+// Infoln formats using the default formats for its operands and writes to
+// the logger's stream if l.Level is INFO or lower. Spaces are always added
+// between operands and a newline is appended.
+func (l *Logger) Infoln(v ...interface{}) (n int, err error) {
+	if l.Level > INFO {
+		return 0, nil
+	}
+	return l.Fprint(2, INFO, fmt.Sprintln(v...), nil)
+}
+
+// Infof formats according to a format specifier and writes to the logger's
+// stream if l.Level is INFO or lower.
+func (l *Logger) Infof(format string, v ...interface{}) (n int, err error) {
+	if l.Level > INFO {
+		return 0, nil
+	}
+	return l.Fprint(2, INFO, fmt.Sprintf(format, v...), nil)
+}
+
+// Warning sends output to the logger's stream if l.Level is WARNING or
+// lower. Spaces are added between operands when neither is a string.
+func (l *Logger) Warning(v ...interface{}) (n int, err error) {
+	if l.Level > WARNING {
+		return 0, nil
+	}
+	return l.Fprint(2, WARNING, fmt.Sprint(v...), nil)
+}
+
+// Warningln formats using the default formats for its operands and writes to
+// the logger's stream if l.Level is WARNING or lower. Spaces are always
+// added between operands and a newline is appended.
+func (l *Logger) Warningln(v ...interface{}) (n int, err error) {
+	if l.Level > WARNING {
+		return 0, nil
+	}
+	return l.Fprint(2, WARNING, fmt.Sprintln(v...), nil)
+}
+
+// Warningf formats according to a format specifier and writes to the
+// logger's stream if l.Level is WARNING or lower.
+func (l *Logger) Warningf(format string, v ...interface{}) (n int, err error) {
+	if l.Level > WARNING {
+		return 0, nil
+	}
+	return l.Fprint(2, WARNING, fmt.Sprintf(format, v...), nil)
+}
+
+// Error sends output to the logger's stream if l.Level is ERROR or lower.
+// Spaces are added between operands when neither is a string.
+func (l *Logger) Error(v ...interface{}) (n int, err error) {
+	if l.Level > ERROR {
+		return 0, nil
+	}
+	return l.Fprint(2, ERROR, fmt.Sprint(v...), nil)
+}
+
+// Errorln formats using the default formats for its operands and writes to
+// the logger's stream if l.Level is ERROR or lower. Spaces are always added
+// between operands and a newline is appended.
+func (l *Logger) Errorln(v ...interface{}) (n int, err error) {
+	if l.Level > ERROR {
+		return 0, nil
+	}
+	return l.Fprint(2, ERROR, fmt.Sprintln(v...), nil)
+}
+
+// Errorf formats according to a format specifier and writes to the logger's
+// stream if l.Level is ERROR or lower.
+func (l *Logger) Errorf(format string, v ...interface{}) (n int, err error) {
+	if l.Level > ERROR {
+		return 0, nil
+	}
+	return l.Fprint(2, ERROR, fmt.Sprintf(format, v...), nil)
+}
+
+// Critical sends output to the logger's stream. Spaces are added between
+// operands when neither is a string.
+func (l *Logger) Critical(v ...interface{}) (n int, err error) {
+	if l.Level > CRITICAL {
+		return 0, nil
+	}
+	return l.Fprint(2, CRITICAL, fmt.Sprint(v...), nil)
+}
+
+// Criticalln formats using the default formats for its operands and writes
+// to the logger's stream. Spaces are always added between operands and a
+// newline is appended.
+func (l *Logger) Criticalln(v ...interface{}) (n int, err error) {
+	if l.Level > CRITICAL {
+		return 0, nil
+	}
+	return l.Fprint(2, CRITICAL, fmt.Sprintln(v...), nil)
+}
+
+// Criticalf formats according to a format specifier and writes to the
+// logger's stream.
+func (l *Logger) Criticalf(format string, v ...interface{}) (n int, err error) {
+	if l.Level > CRITICAL {
+		return 0, nil
+	}
+	return l.Fprint(2, CRITICAL, fmt.Sprintf(format, v...), nil)
+}
+
+// Fatal sends output to the logger's stream regardless of l.Level and then
+// calls os.Exit(1).
+func (l *Logger) Fatal(v ...interface{}) {
+	l.Fprint(2, CRITICAL, fmt.Sprint(v...), nil)
+	os.Exit(1)
+}
+
+// Fatalln formats using the default formats for its operands, writes to the
+// logger's stream regardless of l.Level, and then calls os.Exit(1).
+func (l *Logger) Fatalln(v ...interface{}) {
+	l.Fprint(2, CRITICAL, fmt.Sprintln(v...), nil)
+	os.Exit(1)
+}
+
+// Fatalf formats according to a format specifier, writes to the logger's
+// stream regardless of l.Level, and then calls os.Exit(1).
+func (l *Logger) Fatalf(format string, v ...interface{}) {
+	l.Fprint(2, CRITICAL, fmt.Sprintf(format, v...), nil)
+	os.Exit(1)
+}
+
+// Panic sends output to the logger's stream regardless of l.Level and then
+// panics with the formatted string.
+func (l *Logger) Panic(v ...interface{}) {
+	s := fmt.Sprint(v...)
+	l.Fprint(2, CRITICAL, s, nil)
+	panic(s)
+}
+
+// Panicln formats using the default formats for its operands, writes to the
+// logger's stream regardless of l.Level, and then panics with the formatted
+// string.
+func (l *Logger) Panicln(v ...interface{}) {
+	s := fmt.Sprintln(v...)
+	l.Fprint(2, CRITICAL, s, nil)
+	panic(s)
+}
+
+// Panicf formats according to a format specifier, writes to the logger's
+// stream regardless of l.Level, and then panics with the formatted string.
+func (l *Logger) Panicf(format string, v ...interface{}) {
+	s := fmt.Sprintf(format, v...)
+	l.Fprint(2, CRITICAL, s, nil)
+	panic(s)
 }
 
 // New creates a new logger object and returns it.
@@ -169,5 +442,8 @@ func New(stream io.Writer, level level) (obj *Logger) {
 	obj = &Logger{Stream: stream, Colors: true, DateFormat: time.RubyDate,
 		Flags: LstdFlags, Level: level, Template: tmpl,
 		Prefix: defColorPrefix}
+	obj.Encoder = &TextEncoder{l: obj}
+	obj.Sinks = []*Sink{{Level: DEBUG, Writer: stream}}
+	obj.vmodCache.Store(&sync.Map{})
 	return
 }
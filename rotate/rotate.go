@@ -0,0 +1,289 @@
+// Copyright 2013 The go-logger Authors. All rights reserved.
+// This code is MIT licensed. See the LICENSE file for more info.
+
+// Package rotate provides a size- and time-based rotating io.Writer for use
+// as a logger.Logger's Stream, addressing the lack of built-in log file
+// cutting in the standard library logger.
+package rotate
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// RotatingFileWriter is an io.Writer that writes to Filename, rotating it
+// out for a fresh file once a size or time-of-day threshold is crossed.
+// Backups beyond MaxBackups or older than MaxAge are pruned, and pruning
+// plus gzip compression happen off the write path in a background
+// goroutine. A RotatingFileWriter must not be copied after first use.
+type RotatingFileWriter struct {
+	// Filename is the active log file. Backups are written alongside it
+	// as "<name>-YYYYMMDD-HHMMSS<ext>" (plus ".gz" when Compress is set).
+	Filename string
+	// MaxSizeBytes rotates the file once its size would exceed this
+	// value. Zero disables size-based rotation.
+	MaxSizeBytes int64
+	// MaxAge prunes backups older than this duration. Zero disables
+	// age-based pruning.
+	MaxAge time.Duration
+	// MaxBackups prunes backups beyond this count, oldest first. Zero
+	// disables count-based pruning.
+	MaxBackups int
+	// Compress gzips rotated-out backups in the background.
+	Compress bool
+	// RotateAt, if non-zero, is a time-of-day (offset from midnight)
+	// at which the file is rotated regardless of size, mirroring a
+	// daily cron-like trigger.
+	RotateAt time.Duration
+
+	mu           sync.Mutex
+	file         *os.File
+	size         int64
+	nextRotateAt time.Time
+
+	hupOnce sync.Once
+	hupStop chan struct{}
+}
+
+// New returns a RotatingFileWriter for filename with no size, age, or
+// backup limits; callers set the fields they want before first Write.
+func New(filename string) *RotatingFileWriter {
+	return &RotatingFileWriter{Filename: filename}
+}
+
+// Write implements io.Writer. It opens Filename on first use, rotates it
+// out when p would push it past MaxSizeBytes or when RotateAt has passed,
+// and serializes all of this with a mutex so no line is split across
+// files.
+func (w *RotatingFileWriter) Write(p []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		if err = w.openExisting(); err != nil {
+			return 0, err
+		}
+	}
+	if w.dueForRotation(int64(len(p))) {
+		if err = w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err = w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// dueForRotation reports whether writing n more bytes should trigger a
+// rotation before the write happens.
+func (w *RotatingFileWriter) dueForRotation(n int64) bool {
+	if w.MaxSizeBytes > 0 && w.size+n > w.MaxSizeBytes {
+		return true
+	}
+	if w.RotateAt > 0 && !w.nextRotateAt.IsZero() && timeNow().After(w.nextRotateAt) {
+		return true
+	}
+	return false
+}
+
+// openExisting opens Filename, creating it if necessary, and picks up its
+// current size so MaxSizeBytes is honored across process restarts.
+func (w *RotatingFileWriter) openExisting() error {
+	f, err := os.OpenFile(w.Filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("rotate: open %s: %w", w.Filename, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("rotate: stat %s: %w", w.Filename, err)
+	}
+	w.file = f
+	w.size = info.Size()
+	w.scheduleNextRotateAt(timeNow())
+	return nil
+}
+
+// scheduleNextRotateAt sets nextRotateAt to the next occurrence of
+// RotateAt (a time-of-day offset) strictly after now.
+func (w *RotatingFileWriter) scheduleNextRotateAt(now time.Time) {
+	if w.RotateAt <= 0 {
+		return
+	}
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	next := midnight.Add(w.RotateAt)
+	if !next.After(now) {
+		next = next.Add(24 * time.Hour)
+	}
+	w.nextRotateAt = next
+}
+
+// rotate closes the active file, renames it to a timestamped backup, and
+// opens a fresh file in its place. Backup compression and pruning of
+// excess backups happen asynchronously.
+func (w *RotatingFileWriter) rotate() error {
+	now := timeNow()
+	if w.file != nil {
+		w.file.Close()
+	}
+	backup := backupName(w.Filename, now)
+	if err := os.Rename(w.Filename, backup); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("rotate: rename %s: %w", w.Filename, err)
+	}
+	f, err := os.OpenFile(w.Filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("rotate: open %s: %w", w.Filename, err)
+	}
+	w.file = f
+	w.size = 0
+	w.scheduleNextRotateAt(now)
+	go w.cleanup(backup)
+	return nil
+}
+
+// cleanup runs off the hot path: it optionally gzips the just-rotated
+// backup, then prunes backups beyond MaxBackups or older than MaxAge.
+func (w *RotatingFileWriter) cleanup(backup string) {
+	if w.Compress {
+		if compressed, err := gzipFile(backup); err == nil {
+			backup = compressed
+		}
+	}
+	backups, err := w.listBackups()
+	if err != nil {
+		return
+	}
+	now := timeNow()
+	for i, b := range backups {
+		tooOld := w.MaxAge > 0 && now.Sub(b.modTime) > w.MaxAge
+		overflow := w.MaxBackups > 0 && i < len(backups)-w.MaxBackups
+		if tooOld || overflow {
+			os.Remove(b.path)
+		}
+	}
+}
+
+// backupInfo describes a rotated-out backup file on disk.
+type backupInfo struct {
+	path    string
+	modTime time.Time
+}
+
+// listBackups returns the backups for Filename sorted oldest first.
+func (w *RotatingFileWriter) listBackups() ([]backupInfo, error) {
+	dir := filepath.Dir(w.Filename)
+	base := filepath.Base(w.Filename)
+	ext := filepath.Ext(base)
+	prefix := strings.TrimSuffix(base, ext) + "-"
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var backups []backupInfo
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasPrefix(e.Name(), prefix) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backupInfo{path: filepath.Join(dir, e.Name()), modTime: info.ModTime()})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].modTime.Before(backups[j].modTime) })
+	return backups, nil
+}
+
+// backupName builds the timestamped backup path for filename at t.
+func backupName(filename string, t time.Time) string {
+	ext := filepath.Ext(filename)
+	base := strings.TrimSuffix(filename, ext)
+	return fmt.Sprintf("%s-%s%s", base, t.Format("20060102-150405"), ext)
+}
+
+// gzipFile compresses path to path+".gz" and removes the uncompressed
+// original, returning the new path.
+func gzipFile(path string) (string, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	dstPath := path + ".gz"
+	dst, err := os.OpenFile(dstPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return "", err
+	}
+	gw := gzip.NewWriter(dst)
+	if _, err := io.Copy(gw, src); err != nil {
+		gw.Close()
+		dst.Close()
+		return "", err
+	}
+	if err := gw.Close(); err != nil {
+		dst.Close()
+		return "", err
+	}
+	if err := dst.Close(); err != nil {
+		return "", err
+	}
+	os.Remove(path)
+	return dstPath, nil
+}
+
+// ListenForSIGHUP starts a background goroutine that reopens Filename on
+// receipt of SIGHUP, for use with external logrotate-style tools that
+// rename the file out from under the process. Call Stop to release the
+// signal handler.
+func (w *RotatingFileWriter) ListenForSIGHUP() {
+	w.hupOnce.Do(func() {
+		w.hupStop = make(chan struct{})
+		sigc := make(chan os.Signal, 1)
+		signal.Notify(sigc, syscall.SIGHUP)
+		go func() {
+			for {
+				select {
+				case <-sigc:
+					w.mu.Lock()
+					if w.file != nil {
+						w.file.Close()
+					}
+					w.openExisting()
+					w.mu.Unlock()
+				case <-w.hupStop:
+					signal.Stop(sigc)
+					return
+				}
+			}
+		}()
+	})
+}
+
+// Stop releases the SIGHUP handler started by ListenForSIGHUP, if any.
+func (w *RotatingFileWriter) Stop() {
+	if w.hupStop != nil {
+		close(w.hupStop)
+	}
+}
+
+// Close closes the active file.
+func (w *RotatingFileWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	return w.file.Close()
+}
+
+// timeNow is a var so tests can stub it; production code always uses the
+// real wall clock.
+var timeNow = time.Now
@@ -0,0 +1,168 @@
+// Copyright 2013 The go-logger Authors. All rights reserved.
+// This code is MIT licensed. See the LICENSE file for more info.
+
+package logger
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// siteShards is the number of shards used to spread call-site state across
+// independent mutexes, so a chatty call site doesn't serialize unrelated
+// ones.
+const siteShards = 32
+
+// rateSpec is the perSecond/burst configuration installed by RateLimit for
+// one level.
+type rateSpec struct {
+	perSecond float64
+	burst     float64
+}
+
+// siteState is the token-bucket and sampling state tracked per (level,
+// file, line) call site.
+type siteState struct {
+	mu        sync.Mutex
+	tokens    float64
+	lastFill  time.Time
+	count     uint64    // running count for SampleEvery
+	dropped   uint64    // consecutive entries suppressed since the last admission
+	firstDrop time.Time // when the current suppression streak began, for SummarizeDropped
+}
+
+// takeToken applies spec's token-bucket refill up to now and reports
+// whether a token was available. st.mu must be held by the caller.
+func (st *siteState) takeToken(spec rateSpec, now time.Time) bool {
+	if st.lastFill.IsZero() {
+		st.tokens = spec.burst
+	} else if elapsed := now.Sub(st.lastFill).Seconds(); elapsed > 0 {
+		st.tokens += elapsed * spec.perSecond
+		if st.tokens > spec.burst {
+			st.tokens = spec.burst
+		}
+	}
+	st.lastFill = now
+	if st.tokens < 1 {
+		return false
+	}
+	st.tokens--
+	return true
+}
+
+// siteShard is one shard of a Logger's call-site state map.
+type siteShard struct {
+	mu sync.Mutex
+	m  map[uint64]*siteState
+}
+
+// siteKey hashes (lvl, file, line) into a single key for the shard map.
+func siteKey(lvl level, file string, line int) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte{byte(lvl)})
+	h.Write([]byte(file))
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], uint64(line))
+	h.Write(b[:])
+	return h.Sum64()
+}
+
+// siteState returns the call-site state for key, creating it on first use.
+func (l *Logger) siteState(key uint64) *siteState {
+	shard := &l.shards[key%siteShards]
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	if shard.m == nil {
+		shard.m = make(map[uint64]*siteState)
+	}
+	st, ok := shard.m[key]
+	if !ok {
+		st = &siteState{}
+		shard.m[key] = st
+	}
+	return st
+}
+
+// limitersInstalled is the fast, lock-free check Fprint uses to skip
+// call-site resolution entirely when neither SampleEvery nor RateLimit has
+// ever been used.
+func (l *Logger) limitersInstalled() bool {
+	return atomic.LoadInt32(&l.limiters) != 0
+}
+
+// admitResult is the outcome of checking a call site against the
+// installed sampler and rate limiter.
+type admitResult struct {
+	ok       bool
+	repeated uint64
+	since    time.Duration // how long the repeated entries were suppressed for
+}
+
+// admit applies SampleEvery and RateLimit, in that order, to the call site
+// identified by (lvl, file, line), and reports how many prior entries were
+// suppressed there, and for how long, if SummarizeDropped is set.
+func (l *Logger) admit(lvl level, file string, line int, now time.Time) admitResult {
+	st := l.siteState(siteKey(lvl, file, line))
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	allow := true
+	if n := atomic.LoadUint64(&l.sampleEvery); n > 0 {
+		st.count++
+		allow = st.count%n == 0
+	}
+	if allow {
+		l.rateMu.RLock()
+		spec, ok := l.rateLimits[lvl]
+		l.rateMu.RUnlock()
+		if ok {
+			allow = st.takeToken(spec, now)
+		}
+	}
+	if !allow {
+		if st.dropped == 0 {
+			st.firstDrop = now
+		}
+		st.dropped++
+		return admitResult{ok: false}
+	}
+	var repeated uint64
+	var since time.Duration
+	if st.dropped > 0 {
+		if l.SummarizeDropped {
+			repeated = st.dropped
+			since = now.Sub(st.firstDrop)
+		}
+		st.dropped = 0
+		st.firstDrop = time.Time{}
+	}
+	return admitResult{ok: true, repeated: repeated, since: since}
+}
+
+// SampleEvery logs only 1 in every n entries at each call site, dropping
+// the rest. Passing n <= 0 disables sampling.
+func (l *Logger) SampleEvery(n int) {
+	if n <= 0 {
+		atomic.StoreUint64(&l.sampleEvery, 0)
+		return
+	}
+	atomic.StoreUint64(&l.sampleEvery, uint64(n))
+	atomic.StoreInt32(&l.limiters, 1)
+}
+
+// RateLimit caps entries logged at lvl to perSecond per call site, with
+// bursts of up to burst entries absorbed by a token bucket keyed on
+// (level, file, line). Call sites are independent: a chatty one cannot
+// starve others logging at the same level.
+func (l *Logger) RateLimit(lvl level, perSecond, burst int) {
+	l.rateMu.Lock()
+	if l.rateLimits == nil {
+		l.rateLimits = make(map[level]rateSpec)
+	}
+	l.rateLimits[lvl] = rateSpec{perSecond: float64(perSecond), burst: float64(burst)}
+	l.rateMu.Unlock()
+	atomic.StoreInt32(&l.limiters, 1)
+}
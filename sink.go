@@ -0,0 +1,223 @@
+// Copyright 2013 The go-logger Authors. All rights reserved.
+// This code is MIT licensed. See the LICENSE file for more info.
+
+package logger
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// DropPolicy controls what an async Sink does when its buffer is full.
+type DropPolicy int
+
+const (
+	// DropOldest discards the oldest buffered entry to make room for the
+	// new one.
+	DropOldest DropPolicy = iota
+	// DropNewest discards the entry that was about to be sent.
+	DropNewest
+	// Block waits for room in the buffer, applying backpressure to the
+	// caller.
+	Block
+)
+
+// Sink is one destination a Logger fans entries out to. Each Sink has its
+// own minimum Level and Encoder, so a single Logger can send colored
+// WARNING+ to stderr, plain INFO+ to a rotating file, and ERROR+ to a
+// network sink simultaneously. A Sink must not be copied after first use;
+// share it via pointer.
+type Sink struct {
+	// Level is the minimum severity this sink accepts. Entries that carry
+	// no level always reach every sink; Print, Println and Printf write
+	// straight to os.Stdout and do not go through sinks.
+	Level level
+	// Encoder turns an Entry into bytes for this sink. If nil, the
+	// owning Logger's Encoder is used.
+	Encoder Encoder
+	// Writer is this sink's destination.
+	Writer io.Writer
+	// Async delivers entries on a background goroutine via a buffered
+	// channel instead of writing synchronously.
+	Async bool
+	// BufferSize is the capacity of the async delivery channel. Ignored
+	// unless Async is true.
+	BufferSize int
+	// Drop selects what happens when the async buffer is full.
+	Drop DropPolicy
+
+	mu      sync.Mutex // serializes synchronous writes
+	once    sync.Once
+	ch      chan []byte
+	flushCh chan chan struct{}
+	dropped uint64
+}
+
+// accepts reports whether lvl meets this sink's Level threshold. Entries
+// with no level (noLevel) always pass.
+func (s *Sink) accepts(lvl level) bool {
+	return lvl == noLevel || lvl >= s.Level
+}
+
+// start lazily spins up the background goroutine that drains an async
+// sink's channel.
+func (s *Sink) start() {
+	s.once.Do(func() {
+		s.ch = make(chan []byte, s.BufferSize)
+		s.flushCh = make(chan chan struct{})
+		go s.run()
+	})
+}
+
+// run is the async sink's drain loop.
+func (s *Sink) run() {
+	for {
+		select {
+		case b := <-s.ch:
+			s.Writer.Write(b)
+		case ack := <-s.flushCh:
+			s.drainPending()
+			close(ack)
+		}
+	}
+}
+
+// drainPending flushes any entries already queued without blocking for
+// new ones, used to make Flush wait for previously accepted work.
+func (s *Sink) drainPending() {
+	for {
+		select {
+		case b := <-s.ch:
+			s.Writer.Write(b)
+		default:
+			return
+		}
+	}
+}
+
+// write delivers b to this sink, synchronously or via its async channel
+// according to Drop policy when the channel is full.
+func (s *Sink) write(b []byte) (int, error) {
+	if !s.Async {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.Writer.Write(b)
+	}
+	s.start()
+	select {
+	case s.ch <- b:
+		return len(b), nil
+	default:
+	}
+	switch s.Drop {
+	case DropOldest:
+		select {
+		case <-s.ch:
+			atomic.AddUint64(&s.dropped, 1)
+		default:
+		}
+		select {
+		case s.ch <- b:
+			return len(b), nil
+		default:
+			atomic.AddUint64(&s.dropped, 1)
+			return 0, nil
+		}
+	case Block:
+		s.ch <- b
+		return len(b), nil
+	default: // DropNewest
+		atomic.AddUint64(&s.dropped, 1)
+		return 0, nil
+	}
+}
+
+// Stats reports counters accumulated across a Logger's sinks.
+type Stats struct {
+	// Dropped is the number of entries discarded by async sinks under
+	// DropOldest or DropNewest.
+	Dropped uint64
+}
+
+// AddSink registers s as an additional destination for l's entries.
+func (l *Logger) AddSink(s *Sink) {
+	l.sinksMu.Lock()
+	l.Sinks = append(l.Sinks, s)
+	l.sinksMu.Unlock()
+}
+
+// Stats returns the dropped-entry count summed across all of l's sinks.
+func (l *Logger) Stats() Stats {
+	l.sinksMu.RLock()
+	defer l.sinksMu.RUnlock()
+	var dropped uint64
+	for _, s := range l.Sinks {
+		dropped += atomic.LoadUint64(&s.dropped)
+	}
+	return Stats{Dropped: dropped}
+}
+
+// Flush blocks until every async sink has written out the entries it had
+// already accepted, or until ctx is done.
+func (l *Logger) Flush(ctx context.Context) error {
+	l.sinksMu.RLock()
+	sinks := l.Sinks
+	l.sinksMu.RUnlock()
+	for _, s := range sinks {
+		if !s.Async {
+			continue
+		}
+		s.start()
+		ack := make(chan struct{})
+		select {
+		case s.flushCh <- ack:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		select {
+		case <-ack:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// dispatch encodes e once per distinct Encoder in use and writes the
+// result to every sink whose Level threshold e meets.
+func (l *Logger) dispatch(e Entry) (n int, err error) {
+	l.sinksMu.RLock()
+	sinks := l.Sinks
+	l.sinksMu.RUnlock()
+	encoded := make(map[Encoder][]byte)
+	for _, s := range sinks {
+		if !s.accepts(e.Level) {
+			continue
+		}
+		enc := s.Encoder
+		if enc == nil {
+			enc = l.Encoder
+		}
+		b, ok := encoded[enc]
+		if !ok {
+			var buf bytes.Buffer
+			if encErr := enc.Encode(&buf, e); encErr != nil {
+				if err == nil {
+					err = encErr
+				}
+				continue
+			}
+			b = buf.Bytes()
+			encoded[enc] = b
+		}
+		wn, werr := s.write(b)
+		n += wn
+		if werr != nil && err == nil {
+			err = werr
+		}
+	}
+	return n, err
+}
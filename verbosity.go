@@ -0,0 +1,187 @@
+// Copyright 2013 The go-logger Authors. All rights reserved.
+// This code is MIT licensed. See the LICENSE file for more info.
+
+package logger
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Verbose is returned by Logger.V and carries whether logging at the
+// requested verbosity level is enabled, along with the *Logger that
+// produced it. Its Info/Infof/Infoln methods are a no-op unless the level
+// check passed, so a cheap guard (log.V(2).Info(...)) avoids formatting
+// when verbosity is too low; they log through the originating Logger, not
+// the package default, so a non-default logger's sinks, level, and
+// encoder are honored.
+type Verbose struct {
+	enabled bool
+	l       *Logger
+}
+
+// Info logs its arguments if v is enabled, in the manner of Logger.Info.
+func (v Verbose) Info(args ...interface{}) {
+	if v.enabled {
+		v.l.Info(args...)
+	}
+}
+
+// Infoln logs its arguments if v is enabled, in the manner of Logger.Infoln.
+func (v Verbose) Infoln(args ...interface{}) {
+	if v.enabled {
+		v.l.Infoln(args...)
+	}
+}
+
+// Infof logs its arguments if v is enabled, in the manner of Logger.Infof.
+func (v Verbose) Infof(format string, args ...interface{}) {
+	if v.enabled {
+		v.l.Infof(format, args...)
+	}
+}
+
+// noVmodMatch is cached for a call site whose file matched no vmodule
+// pattern, so vAt can distinguish "no override" from a real level 0
+// override without re-running the pattern match.
+const noVmodMatch = -1
+
+// V reports whether verbosity level n is enabled on l, either because n is
+// at or below l.Verbosity, or because the caller's source file matches a
+// pattern registered with SetVModule at or above level n. Only the
+// vmodule-pattern match for a call site is cached (per program counter,
+// via a single sync.Map lookup); l.Verbosity is read fresh on every call
+// so raising or lowering it, including via a late-parsed -v flag, takes
+// effect immediately even at already-cached call sites.
+func (l *Logger) V(n int) Verbose {
+	return l.vAt(n, 2)
+}
+
+// vAt is the shared implementation of V. skip is the runtime.Caller depth
+// of the original caller whose source file should be checked against the
+// installed vmodule patterns: 2 for both a direct l.V(n) call and the
+// package-level V helper, since both sit one frame above vAt itself.
+func (l *Logger) vAt(n, skip int) Verbose {
+	effective := l.Verbosity
+	pc, file, _, ok := runtime.Caller(skip)
+	if !ok {
+		return Verbose{enabled: n <= effective, l: l}
+	}
+	cache := l.vmodCache.Load().(*sync.Map)
+	if cached, ok := cache.Load(pc); ok {
+		if lvl := cached.(int); lvl > effective {
+			effective = lvl
+		}
+		return Verbose{enabled: n <= effective, l: l}
+	}
+	matchedLevel := noVmodMatch
+	if l.vmodule != nil {
+		if lvl, matched := l.vmodule.match(file); matched {
+			matchedLevel = lvl
+			if lvl > effective {
+				effective = lvl
+			}
+		}
+	}
+	cache.Store(pc, matchedLevel)
+	return Verbose{enabled: n <= effective, l: l}
+}
+
+// vmodulePattern is a single "pattern=level" entry parsed from a vmodule
+// spec, compiled to a regular expression for matching against a call
+// site's file path or base name.
+type vmodulePattern struct {
+	level int
+	re    *regexp.Regexp
+}
+
+// vmoduleConfig holds the compiled set of vmodule patterns installed by
+// SetVModule.
+type vmoduleConfig struct {
+	patterns []vmodulePattern
+}
+
+// match returns the highest level among patterns matching file (tested
+// against both the full path and the extension-less base name), and
+// whether any pattern matched at all.
+func (c *vmoduleConfig) match(file string) (level int, matched bool) {
+	base := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	for _, p := range c.patterns {
+		if p.re.MatchString(file) || p.re.MatchString(base) {
+			matched = true
+			if p.level > level {
+				level = p.level
+			}
+		}
+	}
+	return level, matched
+}
+
+// SetVModule installs per-file/per-package verbosity overrides from a
+// comma-separated list of pattern=N settings, e.g.
+// "file1=2,pkg/*=3". Patterns use shell-glob syntax ("*" matches any run
+// of characters) and are matched against both the full caller path and
+// its extension-less base name. SetVModule replaces any previously
+// installed patterns and invalidates the per-call-site cache used by V.
+func (l *Logger) SetVModule(spec string) error {
+	var patterns []vmodulePattern
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("logger: invalid vmodule setting %q", part)
+		}
+		lvl, err := strconv.Atoi(kv[1])
+		if err != nil {
+			return fmt.Errorf("logger: invalid vmodule level in %q: %v", part, err)
+		}
+		re, err := compileGlob(kv[0])
+		if err != nil {
+			return fmt.Errorf("logger: invalid vmodule pattern %q: %v", part, err)
+		}
+		patterns = append(patterns, vmodulePattern{level: lvl, re: re})
+	}
+	l.mu.Lock()
+	l.vmodule = &vmoduleConfig{patterns: patterns}
+	l.vmodCache.Store(&sync.Map{})
+	l.mu.Unlock()
+	return nil
+}
+
+// compileGlob translates a shell-glob pattern ("*" and "?" wildcards) into
+// a regular expression anchored at the end and, at the start, at the
+// beginning of the string or of any path element, so "pkg/*" matches
+// "/src/pkg/file.go".
+func compileGlob(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^(.*/)?")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteByte('$')
+	return regexp.Compile(b.String())
+}
+
+// SetVerbosityFromFlags registers -v and -vmodule with the standard flag
+// package, binding them to l.Verbosity and l.SetVModule respectively. It
+// should be called before flag.Parse.
+func (l *Logger) SetVerbosityFromFlags() {
+	flag.IntVar(&l.Verbosity, "v", l.Verbosity, "log verbosity level")
+	flag.Func("vmodule", "comma-separated list of pattern=N verbosity overrides", l.SetVModule)
+}